@@ -0,0 +1,658 @@
+// Code in this file mirrors what `protoc --go_out=. --go-grpc_out=. fsrpc.proto`
+// would generate from fsrpc.proto; it is checked in by hand because this tree
+// has no protoc toolchain available. Regenerate it for real instead of editing
+// by hand once protoc-gen-go and protoc-gen-go-grpc are on the PATH.
+package fsrpc
+
+import (
+	"context"
+
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+)
+
+type Attr struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Size uint64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Mode uint32 `protobuf:"varint,3,opt,name=mode,proto3" json:"mode,omitempty"`
+	Nlink uint32 `protobuf:"varint,4,opt,name=nlink,proto3" json:"nlink,omitempty"`
+	MtimeUnixNano int64 `protobuf:"varint,5,opt,name=mtime_unix_nano,proto3" json:"mtime_unix_nano,omitempty"`
+}
+
+func (m *Attr) Reset()         { *m = Attr{} }
+func (m *Attr) String() string { return proto.CompactTextString(m) }
+func (*Attr) ProtoMessage()    {}
+
+type LookupRequest struct {
+	DirInode uint64 `protobuf:"varint,1,opt,name=dir_inode,proto3" json:"dir_inode,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (m *LookupRequest) Reset()         { *m = LookupRequest{} }
+func (m *LookupRequest) String() string { return proto.CompactTextString(m) }
+func (*LookupRequest) ProtoMessage()    {}
+
+type LookupResponse struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+	Attr *Attr `protobuf:"bytes,3,opt,name=attr,proto3" json:"attr,omitempty"`
+}
+
+func (m *LookupResponse) Reset()         { *m = LookupResponse{} }
+func (m *LookupResponse) String() string { return proto.CompactTextString(m) }
+func (*LookupResponse) ProtoMessage()    {}
+
+type GetattrRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *GetattrRequest) Reset()         { *m = GetattrRequest{} }
+func (m *GetattrRequest) String() string { return proto.CompactTextString(m) }
+func (*GetattrRequest) ProtoMessage()    {}
+
+type GetattrResponse struct {
+	Attr *Attr `protobuf:"bytes,1,opt,name=attr,proto3" json:"attr,omitempty"`
+}
+
+func (m *GetattrResponse) Reset()         { *m = GetattrResponse{} }
+func (m *GetattrResponse) String() string { return proto.CompactTextString(m) }
+func (*GetattrResponse) ProtoMessage()    {}
+
+type SetattrRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Attr *Attr `protobuf:"bytes,2,opt,name=attr,proto3" json:"attr,omitempty"`
+	Valid uint32 `protobuf:"varint,3,opt,name=valid,proto3" json:"valid,omitempty"`
+	Generation uint64 `protobuf:"varint,4,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *SetattrRequest) Reset()         { *m = SetattrRequest{} }
+func (m *SetattrRequest) String() string { return proto.CompactTextString(m) }
+func (*SetattrRequest) ProtoMessage()    {}
+
+type ReadRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size uint32 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Generation uint64 `protobuf:"varint,4,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *ReadRequest) Reset()         { *m = ReadRequest{} }
+func (m *ReadRequest) String() string { return proto.CompactTextString(m) }
+func (*ReadRequest) ProtoMessage()    {}
+
+type ReadResponse struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *ReadResponse) Reset()         { *m = ReadResponse{} }
+func (m *ReadResponse) String() string { return proto.CompactTextString(m) }
+func (*ReadResponse) ProtoMessage()    {}
+
+type WriteRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Generation uint64 `protobuf:"varint,4,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+
+type WriteResponse struct {
+	Size uint32 `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (m *WriteResponse) Reset()         { *m = WriteResponse{} }
+func (m *WriteResponse) String() string { return proto.CompactTextString(m) }
+func (*WriteResponse) ProtoMessage()    {}
+
+type ReaddirRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Generation uint64 `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *ReaddirRequest) Reset()         { *m = ReaddirRequest{} }
+func (m *ReaddirRequest) String() string { return proto.CompactTextString(m) }
+func (*ReaddirRequest) ProtoMessage()    {}
+
+type DirEntry struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Inode uint64 `protobuf:"varint,2,opt,name=inode,proto3" json:"inode,omitempty"`
+	Type uint32 `protobuf:"varint,3,opt,name=type,proto3" json:"type,omitempty"`
+}
+
+func (m *DirEntry) Reset()         { *m = DirEntry{} }
+func (m *DirEntry) String() string { return proto.CompactTextString(m) }
+func (*DirEntry) ProtoMessage()    {}
+
+type ReaddirResponse struct {
+	Entries []*DirEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (m *ReaddirResponse) Reset()         { *m = ReaddirResponse{} }
+func (m *ReaddirResponse) String() string { return proto.CompactTextString(m) }
+func (*ReaddirResponse) ProtoMessage()    {}
+
+type CreateRequest struct {
+	DirInode uint64 `protobuf:"varint,1,opt,name=dir_inode,proto3" json:"dir_inode,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Mode uint32 `protobuf:"varint,3,opt,name=mode,proto3" json:"mode,omitempty"`
+}
+
+func (m *CreateRequest) Reset()         { *m = CreateRequest{} }
+func (m *CreateRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateRequest) ProtoMessage()    {}
+
+type CreateResponse struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+	Attr *Attr `protobuf:"bytes,3,opt,name=attr,proto3" json:"attr,omitempty"`
+}
+
+func (m *CreateResponse) Reset()         { *m = CreateResponse{} }
+func (m *CreateResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateResponse) ProtoMessage()    {}
+
+type UnlinkRequest struct {
+	DirInode uint64 `protobuf:"varint,1,opt,name=dir_inode,proto3" json:"dir_inode,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Dir bool `protobuf:"varint,3,opt,name=dir,proto3" json:"dir,omitempty"`
+}
+
+func (m *UnlinkRequest) Reset()         { *m = UnlinkRequest{} }
+func (m *UnlinkRequest) String() string { return proto.CompactTextString(m) }
+func (*UnlinkRequest) ProtoMessage()    {}
+
+type UnlinkResponse struct {
+}
+
+func (m *UnlinkResponse) Reset()         { *m = UnlinkResponse{} }
+func (m *UnlinkResponse) String() string { return proto.CompactTextString(m) }
+func (*UnlinkResponse) ProtoMessage()    {}
+
+type RenameRequest struct {
+	OldDirInode uint64 `protobuf:"varint,1,opt,name=old_dir_inode,proto3" json:"old_dir_inode,omitempty"`
+	OldName string `protobuf:"bytes,2,opt,name=old_name,proto3" json:"old_name,omitempty"`
+	NewDirInode uint64 `protobuf:"varint,3,opt,name=new_dir_inode,proto3" json:"new_dir_inode,omitempty"`
+	NewName string `protobuf:"bytes,4,opt,name=new_name,proto3" json:"new_name,omitempty"`
+}
+
+func (m *RenameRequest) Reset()         { *m = RenameRequest{} }
+func (m *RenameRequest) String() string { return proto.CompactTextString(m) }
+func (*RenameRequest) ProtoMessage()    {}
+
+type RenameResponse struct {
+}
+
+func (m *RenameResponse) Reset()         { *m = RenameResponse{} }
+func (m *RenameResponse) String() string { return proto.CompactTextString(m) }
+func (*RenameResponse) ProtoMessage()    {}
+
+type SymlinkRequest struct {
+	DirInode uint64 `protobuf:"varint,1,opt,name=dir_inode,proto3" json:"dir_inode,omitempty"`
+	NewName string `protobuf:"bytes,2,opt,name=new_name,proto3" json:"new_name,omitempty"`
+	Target string `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+}
+
+func (m *SymlinkRequest) Reset()         { *m = SymlinkRequest{} }
+func (m *SymlinkRequest) String() string { return proto.CompactTextString(m) }
+func (*SymlinkRequest) ProtoMessage()    {}
+
+type ReadlinkRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *ReadlinkRequest) Reset()         { *m = ReadlinkRequest{} }
+func (m *ReadlinkRequest) String() string { return proto.CompactTextString(m) }
+func (*ReadlinkRequest) ProtoMessage()    {}
+
+type ReadlinkResponse struct {
+	Target string `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+}
+
+func (m *ReadlinkResponse) Reset()         { *m = ReadlinkResponse{} }
+func (m *ReadlinkResponse) String() string { return proto.CompactTextString(m) }
+func (*ReadlinkResponse) ProtoMessage()    {}
+
+type FsyncRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *FsyncRequest) Reset()         { *m = FsyncRequest{} }
+func (m *FsyncRequest) String() string { return proto.CompactTextString(m) }
+func (*FsyncRequest) ProtoMessage()    {}
+
+type FsyncResponse struct {
+}
+
+func (m *FsyncResponse) Reset()         { *m = FsyncResponse{} }
+func (m *FsyncResponse) String() string { return proto.CompactTextString(m) }
+func (*FsyncResponse) ProtoMessage()    {}
+
+type ForgetRequest struct {
+	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	N uint64 `protobuf:"varint,2,opt,name=n,proto3" json:"n,omitempty"`
+	Generation uint64 `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
+}
+
+func (m *ForgetRequest) Reset()         { *m = ForgetRequest{} }
+func (m *ForgetRequest) String() string { return proto.CompactTextString(m) }
+func (*ForgetRequest) ProtoMessage()    {}
+
+type ForgetResponse struct {
+}
+
+func (m *ForgetResponse) Reset()         { *m = ForgetResponse{} }
+func (m *ForgetResponse) String() string { return proto.CompactTextString(m) }
+func (*ForgetResponse) ProtoMessage()    {}
+
+// FSClient is the client API for the FS service.
+type FSClient interface {
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	Getattr(ctx context.Context, in *GetattrRequest, opts ...grpc.CallOption) (*GetattrResponse, error)
+	Setattr(ctx context.Context, in *SetattrRequest, opts ...grpc.CallOption) (*GetattrResponse, error)
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error)
+	Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
+	Readdir(ctx context.Context, in *ReaddirRequest, opts ...grpc.CallOption) (*ReaddirResponse, error)
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	Unlink(ctx context.Context, in *UnlinkRequest, opts ...grpc.CallOption) (*UnlinkResponse, error)
+	Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*RenameResponse, error)
+	Symlink(ctx context.Context, in *SymlinkRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	Readlink(ctx context.Context, in *ReadlinkRequest, opts ...grpc.CallOption) (*ReadlinkResponse, error)
+	Fsync(ctx context.Context, in *FsyncRequest, opts ...grpc.CallOption) (*FsyncResponse, error)
+	Forget(ctx context.Context, in *ForgetRequest, opts ...grpc.CallOption) (*ForgetResponse, error)
+}
+
+type fSClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFSClient(cc grpc.ClientConnInterface) FSClient {
+	return &fSClient{cc}
+}
+
+func (c *fSClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	out := new(LookupResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Lookup", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Getattr(ctx context.Context, in *GetattrRequest, opts ...grpc.CallOption) (*GetattrResponse, error) {
+	out := new(GetattrResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Getattr", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Setattr(ctx context.Context, in *SetattrRequest, opts ...grpc.CallOption) (*GetattrResponse, error) {
+	out := new(GetattrResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Setattr", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error) {
+	out := new(ReadResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Read", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error) {
+	out := new(WriteResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Write", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Readdir(ctx context.Context, in *ReaddirRequest, opts ...grpc.CallOption) (*ReaddirResponse, error) {
+	out := new(ReaddirResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Readdir", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Create", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Unlink(ctx context.Context, in *UnlinkRequest, opts ...grpc.CallOption) (*UnlinkResponse, error) {
+	out := new(UnlinkResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Unlink", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*RenameResponse, error) {
+	out := new(RenameResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Rename", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Symlink(ctx context.Context, in *SymlinkRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	out := new(LookupResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Symlink", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Readlink(ctx context.Context, in *ReadlinkRequest, opts ...grpc.CallOption) (*ReadlinkResponse, error) {
+	out := new(ReadlinkResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Readlink", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Fsync(ctx context.Context, in *FsyncRequest, opts ...grpc.CallOption) (*FsyncResponse, error) {
+	out := new(FsyncResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Fsync", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSClient) Forget(ctx context.Context, in *ForgetRequest, opts ...grpc.CallOption) (*ForgetResponse, error) {
+	out := new(ForgetResponse)
+	if err := c.cc.Invoke(ctx, "/fsrpc.FS/Forget", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FSServer is the server API for the FS service.
+type FSServer interface {
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	Getattr(context.Context, *GetattrRequest) (*GetattrResponse, error)
+	Setattr(context.Context, *SetattrRequest) (*GetattrResponse, error)
+	Read(context.Context, *ReadRequest) (*ReadResponse, error)
+	Write(context.Context, *WriteRequest) (*WriteResponse, error)
+	Readdir(context.Context, *ReaddirRequest) (*ReaddirResponse, error)
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	Unlink(context.Context, *UnlinkRequest) (*UnlinkResponse, error)
+	Rename(context.Context, *RenameRequest) (*RenameResponse, error)
+	Symlink(context.Context, *SymlinkRequest) (*LookupResponse, error)
+	Readlink(context.Context, *ReadlinkRequest) (*ReadlinkResponse, error)
+	Fsync(context.Context, *FsyncRequest) (*FsyncResponse, error)
+	Forget(context.Context, *ForgetRequest) (*ForgetResponse, error)
+}
+
+func RegisterFSServer(s grpc.ServiceRegistrar, srv FSServer) {
+	s.RegisterService(&FS_ServiceDesc, srv)
+}
+
+func _FS_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Lookup"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Getattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Getattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Getattr"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Getattr(ctx, req.(*GetattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Setattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Setattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Setattr"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Setattr(ctx, req.(*SetattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Read"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Read(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Write_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Write(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Write"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Write(ctx, req.(*WriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Readdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReaddirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Readdir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Readdir"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Readdir(ctx, req.(*ReaddirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Unlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Unlink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Unlink"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Unlink(ctx, req.(*UnlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Rename(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Rename"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Rename(ctx, req.(*RenameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Symlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SymlinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Symlink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Symlink"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Symlink(ctx, req.(*SymlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Readlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadlinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Readlink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Readlink"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Readlink(ctx, req.(*ReadlinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Fsync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FsyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Fsync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Fsync"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Fsync(ctx, req.(*FsyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FS_Forget_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForgetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSServer).Forget(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fsrpc.FS/Forget"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSServer).Forget(ctx, req.(*ForgetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var FS_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fsrpc.FS",
+	HandlerType: (*FSServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _FS_Lookup_Handler,
+		},
+		{
+			MethodName: "Getattr",
+			Handler:    _FS_Getattr_Handler,
+		},
+		{
+			MethodName: "Setattr",
+			Handler:    _FS_Setattr_Handler,
+		},
+		{
+			MethodName: "Read",
+			Handler:    _FS_Read_Handler,
+		},
+		{
+			MethodName: "Write",
+			Handler:    _FS_Write_Handler,
+		},
+		{
+			MethodName: "Readdir",
+			Handler:    _FS_Readdir_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _FS_Create_Handler,
+		},
+		{
+			MethodName: "Unlink",
+			Handler:    _FS_Unlink_Handler,
+		},
+		{
+			MethodName: "Rename",
+			Handler:    _FS_Rename_Handler,
+		},
+		{
+			MethodName: "Symlink",
+			Handler:    _FS_Symlink_Handler,
+		},
+		{
+			MethodName: "Readlink",
+			Handler:    _FS_Readlink_Handler,
+		},
+		{
+			MethodName: "Fsync",
+			Handler:    _FS_Fsync_Handler,
+		},
+		{
+			MethodName: "Forget",
+			Handler:    _FS_Forget_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "fsrpc.proto",
+}
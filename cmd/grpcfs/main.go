@@ -0,0 +1,361 @@
+// grpcfs mounts a filesystem served by a remote grpcfs server (see
+// ./server), delegating every FUSE op over gRPC so the actual
+// filesystem state lives in that other process.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/idiomatic/fuse-test/cmd/grpcfs/fsrpc"
+)
+
+// rootInode is the well-known inode of the filesystem root, by
+// convention with the server in ./server.
+const rootInode = 1
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s MOUNTPOINT SERVER_ADDR\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		Usage()
+		os.Exit(2)
+	}
+	mountpoint := flag.Arg(0)
+	addr := flag.Arg(1)
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	fsys := &FS{
+		client: fsrpc.NewFSClient(conn),
+		nodes:  newNodeTable(),
+	}
+
+	c, err := fuse.Mount(
+		mountpoint,
+		fuse.FSName("grpc"),
+		fuse.Subtype("grpcfs"),
+		fuse.LocalVolume(),
+		fuse.VolumeName("Remote Filesystem"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+		for {
+			<-sigs
+			fuse.Unmount(mountpoint)
+		}
+	}()
+
+	err = fs.Serve(c, fsys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	<-c.Ready
+	if err := c.MountError; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// FS dials a single grpcfs server and presents it as a bazil.org/fuse
+// filesystem. Node identity is kept consistent across repeated lookups
+// of the same remote inode by nodes, below.
+type FS struct {
+	client fsrpc.FSClient
+	nodes  *nodeTable
+}
+
+func (fsys *FS) Root() (fs.Node, error) {
+	return fsys.nodes.get(fsys, rootInode, 0), nil
+}
+
+// Node represents one remote inode. It implements fs.Node directly as
+// its own fs.Handle (like this module's memfs and testfs examples),
+// since every op is just a round trip to the server anyway.
+type Node struct {
+	fsys *FS
+
+	// inode and generation are this filesystem's equivalent of an NFS
+	// file handle: together they identify one remote file across its
+	// lifetime, surviving local Node object churn.
+	inode      uint64
+	generation uint64
+
+	// refs counts outstanding kernel lookups, mirroring the refcount
+	// FUSE itself expects us to track so Forget can be applied
+	// correctly; see nodeTable.
+	refs uint64
+}
+
+// nodeTable maps remote (inode, generation) pairs to the single Node
+// object representing them, so two Lookups of the same remote file
+// return the same Go value (required for FUSE's node identity and
+// refcounting to work) and so a node can be recycled once forgotten,
+// the way bazil.org/fuse's own serveConn recycles fuse.NodeIDs via a
+// free list.
+type nodeTable struct {
+	mu      sync.Mutex
+	byInode map[uint64]*Node
+	free    []*Node
+}
+
+func newNodeTable() *nodeTable {
+	return &nodeTable{byInode: make(map[uint64]*Node)}
+}
+
+func (t *nodeTable) get(fsys *FS, inode, generation uint64) *Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n, ok := t.byInode[inode]; ok && n.generation == generation {
+		n.refs++
+		return n
+	}
+
+	var n *Node
+	if k := len(t.free); k > 0 {
+		n = t.free[k-1]
+		t.free = t.free[:k-1]
+	} else {
+		n = &Node{fsys: fsys}
+	}
+	n.inode, n.generation, n.refs = inode, generation, 1
+	t.byInode[inode] = n
+	return n
+}
+
+// forget drops count references to n, reclaiming it onto the free list
+// once nothing else holds it.
+func (t *nodeTable) forget(n *Node, count uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if count >= n.refs {
+		if t.byInode[n.inode] == n {
+			delete(t.byInode, n.inode)
+		}
+		t.free = append(t.free, n)
+	} else {
+		n.refs -= count
+	}
+}
+
+// grpcToFuseErr translates the grpc status codes the server uses for
+// filesystem-shaped errors back into fuse.Errno, so the kernel sees
+// ENOENT/EEXIST rather than an opaque RPC failure.
+func grpcToFuseErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fuse.ENOENT
+	case codes.AlreadyExists:
+		return fuse.EEXIST
+	case codes.FailedPrecondition:
+		return fuse.Errno(syscall.ENOTEMPTY)
+	case codes.InvalidArgument:
+		return fuse.EIO
+	default:
+		return err
+	}
+}
+
+func attrFromProto(a *fsrpc.Attr) fuse.Attr {
+	if a == nil {
+		return fuse.Attr{}
+	}
+	return fuse.Attr{
+		Inode: a.Inode,
+		Size:  a.Size,
+		Mode:  os.FileMode(a.Mode),
+		Nlink: a.Nlink,
+		Mtime: time.Unix(0, a.MtimeUnixNano),
+	}
+}
+
+func (n *Node) Attr(ctx context.Context, a *fuse.Attr) error {
+	resp, err := n.fsys.client.Getattr(ctx, &fsrpc.GetattrRequest{Inode: n.inode, Generation: n.generation})
+	if err != nil {
+		return grpcToFuseErr(err)
+	}
+	*a = attrFromProto(resp.Attr)
+	return nil
+}
+
+func (n *Node) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	r, err := n.fsys.client.Setattr(ctx, &fsrpc.SetattrRequest{
+		Inode: n.inode,
+		Attr: &fsrpc.Attr{
+			Size:          req.Size,
+			Mode:          uint32(req.Mode),
+			MtimeUnixNano: req.Mtime.UnixNano(),
+		},
+		Valid:      uint32(req.Valid),
+		Generation: n.generation,
+	})
+	if err != nil {
+		return grpcToFuseErr(err)
+	}
+	resp.Attr = attrFromProto(r.Attr)
+	return nil
+}
+
+func (n *Node) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	resp, err := n.fsys.client.Lookup(ctx, &fsrpc.LookupRequest{DirInode: n.inode, Name: name})
+	if err != nil {
+		return nil, grpcToFuseErr(err)
+	}
+	return n.fsys.nodes.get(n.fsys, resp.Inode, resp.Generation), nil
+}
+
+func (n *Node) Forget() {
+	_, _ = n.fsys.client.Forget(context.Background(), &fsrpc.ForgetRequest{Inode: n.inode, N: n.refs, Generation: n.generation})
+	n.fsys.nodes.forget(n, n.refs)
+}
+
+func (n *Node) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	resp, err := n.fsys.client.Readdir(ctx, &fsrpc.ReaddirRequest{Inode: n.inode, Generation: n.generation})
+	if err != nil {
+		return nil, grpcToFuseErr(err)
+	}
+	dirents := make([]fuse.Dirent, 0, len(resp.Entries))
+	for _, e := range resp.Entries {
+		dirents = append(dirents, fuse.Dirent{
+			Inode: e.Inode,
+			Name:  e.Name,
+			Type:  fuse.DirentType(e.Type),
+		})
+	}
+	return dirents, nil
+}
+
+func (n *Node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	r, err := n.fsys.client.Create(ctx, &fsrpc.CreateRequest{
+		DirInode: n.inode,
+		Name:     req.Name,
+		Mode:     uint32(req.Mode),
+	})
+	if err != nil {
+		return nil, nil, grpcToFuseErr(err)
+	}
+	child := n.fsys.nodes.get(n.fsys, r.Inode, r.Generation)
+	return child, child, nil
+}
+
+func (n *Node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	r, err := n.fsys.client.Create(ctx, &fsrpc.CreateRequest{
+		DirInode: n.inode,
+		Name:     req.Name,
+		Mode:     uint32(req.Mode | os.ModeDir),
+	})
+	if err != nil {
+		return nil, grpcToFuseErr(err)
+	}
+	return n.fsys.nodes.get(n.fsys, r.Inode, r.Generation), nil
+}
+
+func (n *Node) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	_, err := n.fsys.client.Unlink(ctx, &fsrpc.UnlinkRequest{
+		DirInode: n.inode,
+		Name:     req.Name,
+		Dir:      req.Dir,
+	})
+	return grpcToFuseErr(err)
+}
+
+func (n *Node) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	destDir, ok := newDir.(*Node)
+	if !ok {
+		return fuse.EIO
+	}
+	_, err := n.fsys.client.Rename(ctx, &fsrpc.RenameRequest{
+		OldDirInode: n.inode,
+		OldName:     req.OldName,
+		NewDirInode: destDir.inode,
+		NewName:     req.NewName,
+	})
+	return grpcToFuseErr(err)
+}
+
+func (n *Node) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	r, err := n.fsys.client.Symlink(ctx, &fsrpc.SymlinkRequest{
+		DirInode: n.inode,
+		NewName:  req.NewName,
+		Target:   req.Target,
+	})
+	if err != nil {
+		return nil, grpcToFuseErr(err)
+	}
+	return n.fsys.nodes.get(n.fsys, r.Inode, r.Generation), nil
+}
+
+func (n *Node) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	r, err := n.fsys.client.Readlink(ctx, &fsrpc.ReadlinkRequest{Inode: n.inode, Generation: n.generation})
+	if err != nil {
+		return "", grpcToFuseErr(err)
+	}
+	return r.Target, nil
+}
+
+func (n *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	r, err := n.fsys.client.Read(ctx, &fsrpc.ReadRequest{
+		Inode:      n.inode,
+		Offset:     req.Offset,
+		Size:       uint32(req.Size),
+		Generation: n.generation,
+	})
+	if err != nil {
+		return grpcToFuseErr(err)
+	}
+	resp.Data = r.Data
+	return nil
+}
+
+func (n *Node) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	r, err := n.fsys.client.Write(ctx, &fsrpc.WriteRequest{
+		Inode:      n.inode,
+		Offset:     req.Offset,
+		Data:       req.Data,
+		Generation: n.generation,
+	})
+	if err != nil {
+		return grpcToFuseErr(err)
+	}
+	resp.Size = int(r.Size)
+	return nil
+}
+
+func (n *Node) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	_, err := n.fsys.client.Fsync(ctx, &fsrpc.FsyncRequest{Inode: n.inode, Generation: n.generation})
+	return grpcToFuseErr(err)
+}
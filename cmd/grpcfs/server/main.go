@@ -0,0 +1,463 @@
+// server is the trivial in-memory filesystem grpcfs's client mounts
+// over gRPC: it keeps its whole tree in a map, the same way this
+// module's memfs example does, but reachable from another process (or
+// another machine) instead of by direct FUSE calls.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/idiomatic/fuse-test/cmd/grpcfs/fsrpc"
+)
+
+// rootInode matches the client's assumption in ../main.go.
+const rootInode = 1
+
+type kind int
+
+const (
+	kindFile kind = iota
+	kindDir
+	kindSymlink
+)
+
+type inode struct {
+	kind       kind
+	mode       uint32
+	mtime      time.Time
+	generation uint64
+	// refs counts outstanding client lookups (bumped by Lookup and by
+	// alloc's implicit reference, dropped by Forget), mirroring the
+	// kernel lookup count bazil.org/fuse tracks on the client's own
+	// nodeTable. unlinked is set once the last directory entry naming
+	// this inode is gone. The inode is only actually reclaimed once
+	// both are true, so a client that still holds a stale NodeID (has
+	// not yet been told to Forget it) keeps working until it does,
+	// instead of the number being handed to an unrelated new file out
+	// from under it.
+	refs     uint64
+	unlinked bool
+	content  []byte            // kindFile
+	target   string            // kindSymlink
+	children map[string]uint64 // kindDir: name -> child inode
+}
+
+// server is a trivial, single-process implementation of fsrpc.FSServer.
+type server struct {
+	mu sync.Mutex
+
+	nodes map[uint64]*inode
+
+	// free holds inode numbers reclaimed by reclaimIfDone (after both
+	// Unlink and Forget are done with them), available for reuse by the
+	// next Create/Mkdir/Symlink. generation tracks, per inode number, how
+	// many times it has been reused, so a client that
+	// cached a (inode, generation) pair from before a reuse can tell its
+	// handle is stale instead of silently addressing the wrong file --
+	// the scenario the client's nodeTable free list exists to guard
+	// against.
+	free       []uint64
+	generation map[uint64]uint64
+	nextInode  uint64
+}
+
+func newServer() *server {
+	s := &server{
+		nodes:      make(map[uint64]*inode),
+		generation: make(map[uint64]uint64),
+		nextInode:  rootInode,
+	}
+	root := &inode{
+		kind:     kindDir,
+		mode:     uint32(os.ModeDir | 0755),
+		mtime:    time.Now(),
+		children: make(map[string]uint64),
+	}
+	s.nodes[rootInode] = root
+	s.nextInode++
+	return s
+}
+
+// alloc assigns n an inode number, preferring one freed by a prior
+// reclaimIfDone over minting a new one, and bumps that number's generation on
+// reuse. The caller's reference counts as the first of n.refs.
+func (s *server) alloc(n *inode) uint64 {
+	var id uint64
+	if k := len(s.free); k > 0 {
+		id = s.free[k-1]
+		s.free = s.free[:k-1]
+		s.generation[id]++
+	} else {
+		id = s.nextInode
+		s.nextInode++
+	}
+	n.generation = s.generation[id]
+	n.refs = 1
+	s.nodes[id] = n
+	return id
+}
+
+// reclaimIfDone removes id from the live node table and returns its
+// number to the free list for alloc to recycle, once Unlink has dropped
+// its last directory entry and Forget has dropped the client's last
+// reference -- not before, since either one on its own still leaves
+// something addressing this inode.
+func (s *server) reclaimIfDone(id uint64, n *inode) {
+	if n.unlinked && n.refs == 0 {
+		delete(s.nodes, id)
+		s.free = append(s.free, id)
+	}
+}
+
+// get looks up a node by inode number alone, for requests that don't
+// carry a generation (navigating by dir_inode). A dir_inode whose number
+// has since been recycled for another node is not detected here.
+func (s *server) get(id uint64) (*inode, error) {
+	n, ok := s.nodes[id]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no such inode")
+	}
+	return n, nil
+}
+
+// getChecked looks up a node by (inode, generation), for every RPC that
+// addresses a node the client may be holding onto across calls. A
+// generation mismatch means the client's handle refers to an inode
+// number that has since been recycled for a different file, and must be
+// rejected rather than serviced against the wrong node.
+func (s *server) getChecked(id, generation uint64) (*inode, error) {
+	n, ok := s.nodes[id]
+	if !ok || n.generation != generation {
+		return nil, status.Error(codes.NotFound, "no such inode")
+	}
+	return n, nil
+}
+
+func (s *server) attrOf(id uint64, n *inode) *fsrpc.Attr {
+	a := &fsrpc.Attr{
+		Inode:         id,
+		Mode:          n.mode,
+		Nlink:         1,
+		MtimeUnixNano: n.mtime.UnixNano(),
+	}
+	switch n.kind {
+	case kindFile:
+		a.Size = uint64(len(n.content))
+	case kindSymlink:
+		a.Size = uint64(len(n.target))
+	}
+	return a
+}
+
+func direntType(k kind) fuse.DirentType {
+	switch k {
+	case kindDir:
+		return fuse.DT_Dir
+	case kindSymlink:
+		return fuse.DT_Link
+	default:
+		return fuse.DT_File
+	}
+}
+
+func (s *server) Lookup(ctx context.Context, req *fsrpc.LookupRequest) (*fsrpc.LookupResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.get(req.DirInode)
+	if err != nil {
+		return nil, err
+	}
+	if dir.kind != kindDir {
+		return nil, status.Error(codes.InvalidArgument, "not a directory")
+	}
+	childID, ok := dir.children[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no such file or directory")
+	}
+	child := s.nodes[childID]
+	child.refs++
+	return &fsrpc.LookupResponse{Inode: childID, Generation: child.generation, Attr: s.attrOf(childID, child)}, nil
+}
+
+func (s *server) Getattr(ctx context.Context, req *fsrpc.GetattrRequest) (*fsrpc.GetattrResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	return &fsrpc.GetattrResponse{Attr: s.attrOf(req.Inode, n)}, nil
+}
+
+func (s *server) Setattr(ctx context.Context, req *fsrpc.SetattrRequest) (*fsrpc.GetattrResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := fuse.SetattrValid(req.Valid)
+	if valid.Size() && n.kind == kindFile {
+		size := int(req.Attr.Size)
+		switch {
+		case size < len(n.content):
+			n.content = n.content[:size]
+		case size > len(n.content):
+			n.content = append(n.content, make([]byte, size-len(n.content))...)
+		}
+	}
+	if valid.Mode() {
+		n.mode = req.Attr.Mode
+	}
+	if valid.Mtime() {
+		n.mtime = time.Unix(0, req.Attr.MtimeUnixNano)
+	}
+	return &fsrpc.GetattrResponse{Attr: s.attrOf(req.Inode, n)}, nil
+}
+
+func (s *server) Read(ctx context.Context, req *fsrpc.ReadRequest) (*fsrpc.ReadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset >= int64(len(n.content)) {
+		return &fsrpc.ReadResponse{}, nil
+	}
+	end := req.Offset + int64(req.Size)
+	if end > int64(len(n.content)) {
+		end = int64(len(n.content))
+	}
+	return &fsrpc.ReadResponse{Data: append([]byte(nil), n.content[req.Offset:end]...)}, nil
+}
+
+func (s *server) Write(ctx context.Context, req *fsrpc.WriteRequest) (*fsrpc.WriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	end := req.Offset + int64(len(req.Data))
+	if end > int64(len(n.content)) {
+		grown := make([]byte, end)
+		copy(grown, n.content)
+		n.content = grown
+	}
+	copy(n.content[req.Offset:end], req.Data)
+	n.mtime = time.Now()
+	return &fsrpc.WriteResponse{Size: uint32(len(req.Data))}, nil
+}
+
+func (s *server) Readdir(ctx context.Context, req *fsrpc.ReaddirRequest) (*fsrpc.ReaddirResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	if dir.kind != kindDir {
+		return nil, status.Error(codes.InvalidArgument, "not a directory")
+	}
+
+	resp := &fsrpc.ReaddirResponse{}
+	for name, id := range dir.children {
+		resp.Entries = append(resp.Entries, &fsrpc.DirEntry{
+			Name:  name,
+			Inode: id,
+			Type:  uint32(direntType(s.nodes[id].kind)),
+		})
+	}
+	return resp, nil
+}
+
+func (s *server) Create(ctx context.Context, req *fsrpc.CreateRequest) (*fsrpc.CreateResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.get(req.DirInode)
+	if err != nil {
+		return nil, err
+	}
+	if dir.kind != kindDir {
+		return nil, status.Error(codes.InvalidArgument, "not a directory")
+	}
+	if _, exists := dir.children[req.Name]; exists {
+		return nil, status.Error(codes.AlreadyExists, "already exists")
+	}
+
+	n := &inode{mode: req.Mode, mtime: time.Now()}
+	if os.FileMode(req.Mode).IsDir() {
+		n.kind = kindDir
+		n.children = make(map[string]uint64)
+	} else {
+		n.kind = kindFile
+	}
+
+	id := s.alloc(n)
+	dir.children[req.Name] = id
+	dir.mtime = time.Now()
+	return &fsrpc.CreateResponse{Inode: id, Generation: n.generation, Attr: s.attrOf(id, n)}, nil
+}
+
+func (s *server) Unlink(ctx context.Context, req *fsrpc.UnlinkRequest) (*fsrpc.UnlinkResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.get(req.DirInode)
+	if err != nil {
+		return nil, err
+	}
+	childID, ok := dir.children[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no such file or directory")
+	}
+	child := s.nodes[childID]
+	if child.kind == kindDir && len(child.children) > 0 {
+		return nil, status.Error(codes.FailedPrecondition, "directory not empty")
+	}
+
+	delete(dir.children, req.Name)
+	child.unlinked = true
+	s.reclaimIfDone(childID, child)
+	dir.mtime = time.Now()
+	return &fsrpc.UnlinkResponse{}, nil
+}
+
+func (s *server) Rename(ctx context.Context, req *fsrpc.RenameRequest) (*fsrpc.RenameResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldDir, err := s.get(req.OldDirInode)
+	if err != nil {
+		return nil, err
+	}
+	newDir, err := s.get(req.NewDirInode)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := oldDir.children[req.OldName]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no such file or directory")
+	}
+
+	delete(oldDir.children, req.OldName)
+	oldDir.mtime = time.Now()
+	newDir.children[req.NewName] = id
+	newDir.mtime = time.Now()
+	return &fsrpc.RenameResponse{}, nil
+}
+
+func (s *server) Symlink(ctx context.Context, req *fsrpc.SymlinkRequest) (*fsrpc.LookupResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.get(req.DirInode)
+	if err != nil {
+		return nil, err
+	}
+	if dir.kind != kindDir {
+		return nil, status.Error(codes.InvalidArgument, "not a directory")
+	}
+
+	n := &inode{kind: kindSymlink, mode: uint32(os.ModeSymlink | 0777), mtime: time.Now(), target: req.Target}
+	id := s.alloc(n)
+	dir.children[req.NewName] = id
+	dir.mtime = time.Now()
+	return &fsrpc.LookupResponse{Inode: id, Generation: n.generation, Attr: s.attrOf(id, n)}, nil
+}
+
+func (s *server) Readlink(ctx context.Context, req *fsrpc.ReadlinkRequest) (*fsrpc.ReadlinkResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	if n.kind != kindSymlink {
+		return nil, status.Error(codes.InvalidArgument, "not a symlink")
+	}
+	return &fsrpc.ReadlinkResponse{Target: n.target}, nil
+}
+
+func (s *server) Fsync(ctx context.Context, req *fsrpc.FsyncRequest) (*fsrpc.FsyncResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.getChecked(req.Inode, req.Generation); err != nil {
+		return nil, err
+	}
+	return &fsrpc.FsyncResponse{}, nil
+}
+
+// Forget mirrors the kernel dropping req.N references to this inode from
+// its cache, the same event the client's own nodeTable.forget handles
+// locally. A stale or already-reclaimed (inode, generation) is treated as
+// a harmless no-op rather than an error, since the kernel can still send
+// a forget for a node the server (or a concurrent Unlink) already freed.
+func (s *server) Forget(ctx context.Context, req *fsrpc.ForgetRequest) (*fsrpc.ForgetResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.getChecked(req.Inode, req.Generation)
+	if err != nil {
+		return &fsrpc.ForgetResponse{}, nil
+	}
+	if req.N >= n.refs {
+		n.refs = 0
+	} else {
+		n.refs -= req.N
+	}
+	s.reclaimIfDone(req.Inode, n)
+	return &fsrpc.ForgetResponse{}, nil
+}
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s LISTEN_ADDR\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		Usage()
+		os.Exit(2)
+	}
+	addr := flag.Arg(0)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer := grpc.NewServer()
+	fsrpc.RegisterFSServer(grpcServer, newServer())
+
+	log.Printf("grpcfs server listening on %s", addr)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
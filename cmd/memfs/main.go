@@ -9,7 +9,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -28,27 +30,69 @@ type Node interface {
 // function main.nodetype.Attr().
 type attr fuse.Attr
 
+// Each node (Dir, File, Symlink) owns its own mutex rather than sharing
+// one package-level lock, so fs.Serve's concurrent dispatch can really
+// run operations against unrelated parts of the tree in parallel. It
+// guards both attr and whatever content the node holds (children for a
+// Dir, bytes for a File). Operations that touch two nodes at once
+// (Rename, Link) must take both locks via lockOrdered to avoid
+// deadlocking against the same operation running in the opposite
+// direction.
 type Dir struct {
+	mu sync.RWMutex
 	attr
 	children map[string]Node
 }
 
 type File struct {
+	mu sync.RWMutex
 	attr
 	content []byte
 }
 
 type Symlink struct {
+	mu sync.RWMutex
 	attr
 	target string
 }
 
 var (
-	lastInode fuse.NodeID = 0
-	root                  = newDir(0777)
-	mutex     sync.RWMutex
+	lastInode uint64
+	root      = newDir(0777)
+
+	// srv is kept around so out-of-band mutations (anything that does
+	// not arrive as a normal FUSE request) can push cache invalidations
+	// back to the kernel.
+	srv *fs.Server
+
+	// canInvalidate is false on kernels too old to understand
+	// FUSE_NOTIFY_INVAL_INODE/FUSE_NOTIFY_INVAL_ENTRY; invalidation
+	// becomes a silent no-op in that case.
+	canInvalidate bool
 )
 
+// lockOrdered locks two nodes' mutexes in a fixed order (ascending
+// inode number) so that two concurrent multi-node operations (e.g. a
+// Rename from A to B racing a Rename from B to A) can never deadlock by
+// acquiring the pair in opposite order. It returns an unlock function
+// covering both.
+func lockOrdered(aInode uint64, a *sync.RWMutex, bInode uint64, b *sync.RWMutex) func() {
+	if a == b {
+		a.Lock()
+		return a.Unlock
+	}
+	first, second := a, b
+	if bInode < aInode {
+		first, second = b, a
+	}
+	first.Lock()
+	second.Lock()
+	return func() {
+		a.Unlock()
+		b.Unlock()
+	}
+}
+
 func Usage() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s MOUNTPOINT\n", os.Args[0])
@@ -76,6 +120,9 @@ func main() {
 	}
 	defer c.Close()
 
+	canInvalidate = c.Protocol().HasInvalidate()
+	srv = fs.New(c, nil)
+
 	// gracefully shutdown on ctrl-c
 	go func() {
 		sigs := make(chan os.Signal, 1)
@@ -87,7 +134,7 @@ func main() {
 	}()
 
 	// blocks until spontaneous or signalled unmount
-	err = fs.Serve(c, FS{})
+	err = srv.Serve(FS{})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -98,6 +145,41 @@ func main() {
 	}
 }
 
+// invalidateFile notifies the kernel that f's data and attributes are
+// stale, e.g. after content changed without going through f.Write. It
+// is a no-op on kernels without FUSE_NOTIFY_INVAL_INODE support.
+func invalidateFile(f *File) {
+	if !canInvalidate || srv == nil {
+		return
+	}
+	if err := srv.InvalidateNodeData(f); err != nil && err != fuse.ErrNotCached {
+		log.Printf("invalidate %v: %v", f, err)
+	}
+}
+
+// invalidateEntry notifies the kernel that the dentry name under dir is
+// stale, e.g. after a child was created or removed out-of-band.
+func invalidateEntry(dir *Dir, name string) {
+	if !canInvalidate || srv == nil {
+		return
+	}
+	if err := srv.InvalidateEntry(dir, name); err != nil && err != fuse.ErrNotCached {
+		log.Printf("invalidate %v/%s: %v", dir, name, err)
+	}
+}
+
+// Overwrite replaces f's content as if written out-of-band (not via a
+// FUSE Write), and pushes the necessary kernel cache invalidation so
+// readers with f already open or cached see the new data.
+func (f *File) Overwrite(content []byte) {
+	f.mu.Lock()
+	f.content = content
+	f.Mtime = time.Now()
+	f.mu.Unlock()
+
+	invalidateFile(f)
+}
+
 func (FS) Root() (fs.Node, error) {
 	return root, nil
 }
@@ -111,16 +193,16 @@ func (d *Dir) dirent(name string) fuse.Dirent {
 }
 
 func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
 	*a = fuse.Attr(d.attr)
 	return nil
 }
 
 func (d *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
 	if child, ok := d.children[name]; ok {
 		return child, nil
@@ -129,19 +211,25 @@ func (d *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 }
 
 func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	var dir []fuse.Dirent
-	for name, child := range d.children {
-		dir = append(dir, child.dirent(name))
+	names := make([]string, 0, len(d.children))
+	for name := range d.children {
+		names = append(names, name)
 	}
-	return dir, nil
+	sort.Strings(names)
+
+	dirents := make([]fuse.Dirent, 0, len(names))
+	for _, name := range names {
+		dirents = append(dirents, d.children[name].dirent(name))
+	}
+	return dirents, nil
 }
 
 func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
-	mutex.Lock()
-	defer mutex.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	if _, found := d.children[req.Name]; found {
 		return nil, fuse.EEXIST
@@ -153,8 +241,8 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 }
 
 func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
-	mutex.Lock()
-	defer mutex.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	child := newFile(req.Mode)
 	d.children[req.Name] = child
@@ -163,19 +251,22 @@ func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 }
 
 func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if old, ok := old.(*File); ok {
-		d.children[req.NewName] = old
-		d.Mtime = time.Now()
+	oldFile, ok := old.(*File)
+	if !ok {
+		return old, nil
 	}
-	return old, nil
+
+	unlock := lockOrdered(uint64(d.Inode), &d.mu, uint64(oldFile.Inode), &oldFile.mu)
+	defer unlock()
+
+	d.children[req.NewName] = oldFile
+	d.Mtime = time.Now()
+	return oldFile, nil
 }
 
 func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
-	mutex.Lock()
-	defer mutex.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	link := newSymlink(req.Target)
 	d.children[req.NewName] = link
@@ -184,38 +275,79 @@ func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, e
 }
 
 func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	for {
+		// Peek at the current occupant of req.Name to decide which locks
+		// this call needs: just d's if it's not a Dir, or the ordered
+		// pair if it is (so the emptiness check below can read dir.children
+		// safely). This peek is unlocked and therefore stale by the time
+		// the real lock(s) are held, so it must never be trusted for the
+		// actual delete -- that's re-verified below.
+		d.mu.RLock()
+		child := d.children[req.Name]
+		d.mu.RUnlock()
+		if child == nil {
+			return fuse.ENOENT
+		}
 
-	if dir, isDir := d.children[req.Name].(*Dir); isDir {
-		if len(dir.children) > 0 {
-			// target is not empty
+		// If the target is itself a Dir, this touches two nodes, so it
+		// must go through the same ascending-inode ordering as Rename
+		// and Link: locking the parent unconditionally before the child
+		// can deadlock against a concurrent Rename that locks the same
+		// two nodes in the opposite order.
+		dir, isDir := child.(*Dir)
+		var unlock func()
+		if isDir {
+			unlock = lockOrdered(uint64(d.Inode), &d.mu, uint64(dir.Inode), &dir.mu)
+		} else {
+			d.mu.Lock()
+			unlock = d.mu.Unlock
+		}
+
+		// A concurrent Rename could have swapped a different node in
+		// under req.Name (possibly a non-empty directory) between the
+		// peek above and acquiring these locks; re-read the live entry
+		// and start over if it no longer matches what was locked for,
+		// since the right lock set may have changed too.
+		if d.children[req.Name] != child {
+			unlock()
+			continue
+		}
+
+		if isDir && len(dir.children) != 0 {
+			unlock()
 			return fuse.EEXIST
 		}
+
+		delete(d.children, req.Name)
+		d.Mtime = time.Now()
+		unlock()
+		return nil
 	}
-	delete(d.children, req.Name)
-	d.Mtime = time.Now()
-	return nil
 }
 
 func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	destDir, ok := newDir.(*Dir)
+	if !ok {
+		return fuse.ENOENT
+	}
 
-	if destDir, ok := newDir.(*Dir); ok {
-		target := d.children[req.OldName]
-		delete(d.children, req.OldName)
-		d.Mtime = time.Now()
-		destDir.children[req.NewName] = target
-		destDir.Mtime = time.Now()
-		return nil
+	unlock := lockOrdered(uint64(d.Inode), &d.mu, uint64(destDir.Inode), &destDir.mu)
+	defer unlock()
+
+	target, ok := d.children[req.OldName]
+	if !ok {
+		return fuse.ENOENT
 	}
-	return fuse.ENOENT
+	delete(d.children, req.OldName)
+	d.Mtime = time.Now()
+	destDir.children[req.NewName] = target
+	destDir.Mtime = time.Now()
+	return nil
 }
 
 func (d *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	const (
 		handled = fuse.SetattrMode | fuse.SetattrMtime
@@ -243,8 +375,8 @@ func (f *File) dirent(name string) fuse.Dirent {
 }
 
 func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
 	*a = fuse.Attr(f.attr)
 	a.Size = uint64(len(f.content))
@@ -252,16 +384,16 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
 }
 
 func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
 	resp.Data = f.content[req.Offset:][:req.Size]
 	return nil
 }
 
 func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
 	contentLen := int64(len(f.content))
 
@@ -291,8 +423,8 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 }
 
 func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
 	const (
 		handled = fuse.SetattrSize | fuse.SetattrMode | fuse.SetattrMtime
@@ -337,8 +469,8 @@ func (l *Symlink) dirent(name string) fuse.Dirent {
 }
 
 func (l *Symlink) Attr(ctx context.Context, a *fuse.Attr) error {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	*a = fuse.Attr(l.attr)
 	return nil
@@ -348,13 +480,11 @@ func (l *Symlink) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (stri
 	return l.target, nil
 }
 
-//func newNode(mode os.FileMode) attr {
 func newNode(mode os.FileMode) attr {
-	// caller locks
-	lastInode++
+	inode := atomic.AddUint64(&lastInode, 1)
 	now := time.Now()
 	return attr{
-		Inode: uint64(lastInode),
+		Inode: inode,
 		Mode:  mode,
 		Ctime: now,
 		Mtime: now,
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"io"
-	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/fsnotify/fsnotify"
 )
 
 type FileSystem string
@@ -17,48 +21,200 @@ type Dir string
 type File string
 type Symlink string
 
+// FileHandle wraps the *os.File opened for a File so reads, writes and
+// fsyncs go against one open descriptor per fs.Handle instead of
+// reopening the backing path on every call.
+type FileHandle struct {
+	f *os.File
+
+	// appendOnly records whether f was opened with O_APPEND, which makes
+	// WriteAt unusable (os rejects offset-based writes against an
+	// append-mode descriptor); Write falls back to plain Write so the
+	// kernel's own O_APPEND handling still applies.
+	appendOnly bool
+}
+
+// srv and canInvalidate let watchStorage push kernel cache
+// invalidations for changes made directly to storage, bypassing FUSE.
+var (
+	srv           *fs.Server
+	canInvalidate bool
+
+	// readOnlyMount mirrors the -ro flag; when set, Dir and File Attr
+	// report permission bits with the write bits masked off (Symlink
+	// Attr reports them unmasked), matching
+	// the fuse.ReadOnly() mount option applied in main.
+	readOnlyMount bool
+)
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s MOUNTPOINT STORAGE\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
 func main() {
-	var (
-		mountpoint = os.Args[1]
-		storage    = os.Args[2]
-	)
+	ro := flag.Bool("ro", false, "mount read-only instead of as a writable loopback")
+	flag.Usage = Usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		Usage()
+		os.Exit(2)
+	}
+	mountpoint := flag.Arg(0)
+	// Clean so every path derived from storage (Root's backing string,
+	// watchStorage's walk, handleStorageEvent's Dir/File values) agrees
+	// on the same canonical form as Dir.Lookup's filepath.Join, which
+	// is what's actually registered as each node's kernel-visible
+	// identity; otherwise a trailing slash or "./" prefix makes every
+	// invalidation miss its node and get silently swallowed as
+	// ErrNotCached.
+	storage := filepath.Clean(flag.Arg(1))
+	readOnlyMount = *ro
 
-	c, _ := fuse.Mount(
-		mountpoint,
+	options := []fuse.MountOption{
 		fuse.FSName("proxy"),
 		fuse.Subtype("proxyfs"),
 		fuse.LocalVolume(),
-		fuse.ReadOnly(),
 		fuse.VolumeName("Proxy Filesystem"),
-	)
+	}
+	if readOnlyMount {
+		options = append(options, fuse.ReadOnly())
+	}
+
+	c, err := fuse.Mount(mountpoint, options...)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer c.Close()
 
-	_ = fs.Serve(c, FileSystem(storage))
+	canInvalidate = c.Protocol().HasInvalidate()
+	srv = fs.New(c, nil)
+
+	go watchStorage(storage)
+
+	if err := srv.Serve(FileSystem(storage)); err != nil {
+		log.Fatal(err)
+	}
+
+	<-c.Ready
+	if err := c.MountError; err != nil {
+		log.Fatal(err)
+	}
+}
+
+// watchStorage notices changes made to the backing directory tree
+// outside of this mount (another process editing storage directly) and
+// invalidates the corresponding kernel inode/dentry caches so FUSE
+// clients see them. Events are silently dropped on kernels without
+// invalidate support (see handleStorageEvent), and any watcher setup
+// failure is logged and disables watching, since proxyfs remains usable
+// without it.
+func watchStorage(storage string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("proxyfs: cache invalidation disabled: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	err = filepath.Walk(storage, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	})
+	if err != nil {
+		log.Printf("proxyfs: cache invalidation disabled: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			handleStorageEvent(storage, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("proxyfs: watcher error: %v", err)
+		}
+	}
+}
+
+func handleStorageEvent(storage string, event fsnotify.Event) {
+	if !canInvalidate || srv == nil {
+		return
+	}
+
+	rel, err := filepath.Rel(storage, event.Name)
+	if err != nil {
+		return
+	}
+	parent := Dir(filepath.Join(storage, filepath.Dir(rel)))
+	name := filepath.Base(rel)
+
+	switch {
+	case event.Op&(fsnotify.Write|fsnotify.Chmod) != 0:
+		if err := srv.InvalidateNodeData(File(event.Name)); err != nil && err != fuse.ErrNotCached {
+			log.Printf("proxyfs: invalidate %s: %v", event.Name, err)
+		}
+	case event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0:
+		if err := srv.InvalidateEntry(parent, name); err != nil && err != fuse.ErrNotCached {
+			log.Printf("proxyfs: invalidate %s/%s: %v", parent, name, err)
+		}
+	}
 }
 
 func readonly(mode os.FileMode) os.FileMode {
 	return mode & ^os.FileMode(0222)
 }
 
+// fillAttr populates a from an os.Lstat result, including the fields
+// only available through the platform-specific syscall.Stat_t (Atime,
+// Ctime, Nlink, Uid, Gid, Blocks), so tools like "ls -l" and rsync see
+// real metadata instead of just size and mode.
+func fillAttr(a *fuse.Attr, info os.FileInfo) {
+	a.Size = uint64(info.Size())
+	a.Mtime = info.ModTime()
+	a.Mode = info.Mode()
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	a.Inode = stat.Ino
+	a.Nlink = uint32(stat.Nlink)
+	a.Uid = stat.Uid
+	a.Gid = stat.Gid
+	a.Blocks = uint64(stat.Blocks)
+	a.BlockSize = uint32(stat.Blksize)
+	a.Atime = time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+	a.Ctime = time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
+}
+
 func (fs FileSystem) Root() (fs.Node, error) {
 	return Dir(fs), nil
 }
 
 func (d Dir) Attr(ctx context.Context, a *fuse.Attr) error {
-	a.Mode = readonly(os.ModeDir | 0777)
-	info, err := os.Stat(string(d))
+	info, err := os.Lstat(string(d))
 	if err != nil {
 		return err
 	}
-	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-		a.Inode = stat.Ino
+	fillAttr(a, info)
+	if readOnlyMount {
+		a.Mode = readonly(a.Mode)
 	}
 	return nil
 }
 
 func (d Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	p := filepath.Join(string(d), name)
-	info, err := os.Stat(string(p))
+	info, err := os.Lstat(p)
 	if err != nil {
 		return nil, err
 	}
@@ -85,98 +241,225 @@ func direntType(mode os.FileMode) fuse.DirentType {
 	return fuse.DT_Unknown
 }
 
+// readdirChunk bounds how many names each Readdirnames call below pulls
+// from the kernel. ReadDirAll still collects every entry into one slice
+// before returning, so the whole listing is held in memory at once, and
+// a listing is not resumed from a read offset.
+const readdirChunk = 256
+
 func (d Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	entries, err := ioutil.ReadDir(string(d))
+	f, err := os.Open(string(d))
 	if err != nil {
 		return nil, err
 	}
-	var dir []fuse.Dirent
-	for _, entry := range entries {
-		dirent := fuse.Dirent{
-			Type: direntType(entry.Mode()),
-			Name: entry.Name(),
+	defer f.Close()
+
+	var dirents []fuse.Dirent
+	for {
+		names, err := f.Readdirnames(readdirChunk)
+		for _, name := range names {
+			info, err := os.Lstat(filepath.Join(string(d), name))
+			if err != nil {
+				// Entry vanished between Readdirnames and Lstat (e.g.
+				// raced with a concurrent Remove); skip rather than
+				// failing the whole listing.
+				continue
+			}
+			de := fuse.Dirent{
+				Name: name,
+				Type: direntType(info.Mode()),
+			}
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				de.Inode = stat.Ino
+			}
+			dirents = append(dirents, de)
+		}
+		if err == io.EOF {
+			break
 		}
-		if stat, ok := entry.Sys().(*syscall.Stat_t); ok {
-			dirent.Inode = stat.Ino
+		if err != nil {
+			return nil, err
 		}
-		dir = append(dir, dirent)
 	}
-	return dir, nil
+	return dirents, nil
 }
 
-/*
-func (d Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
-	f := File(filepath.Join(string(d), req.Name))
-	// XXX touch?
-	return f, f, nil
+func (d Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	p := filepath.Join(string(d), req.Name)
+	if err := os.Mkdir(p, req.Mode); err != nil {
+		return nil, err
+	}
+	return Dir(p), nil
 }
-*/
 
-func (f File) Attr(ctx context.Context, a *fuse.Attr) error {
-	info, err := os.Stat(string(f))
+func (d Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	p := filepath.Join(string(d), req.Name)
+	actual, err := os.OpenFile(p, int(req.Flags)|os.O_CREATE, req.Mode)
 	if err != nil {
+		return nil, nil, err
+	}
+	return File(p), &FileHandle{f: actual, appendOnly: req.Flags&fuse.OpenAppend != 0}, nil
+}
+
+func (d Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	return os.Remove(filepath.Join(string(d), req.Name))
+}
+
+func (d Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	destDir, ok := newDir.(Dir)
+	if !ok {
+		return fuse.EIO
+	}
+	oldPath := filepath.Join(string(d), req.OldName)
+	newPath := filepath.Join(string(destDir), req.NewName)
+	return os.Rename(oldPath, newPath)
+}
+
+func (d Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	p := filepath.Join(string(d), req.NewName)
+	if err := os.Symlink(req.Target, p); err != nil {
+		return nil, err
+	}
+	return Symlink(p), nil
+}
+
+func (d Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
+	oldFile, ok := old.(File)
+	if !ok {
+		return nil, fuse.EIO
+	}
+	p := filepath.Join(string(d), req.NewName)
+	if err := os.Link(string(oldFile), p); err != nil {
+		return nil, err
+	}
+	return File(p), nil
+}
+
+func (d Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if err := setattr(string(d), req); err != nil {
 		return err
 	}
-	a.Size = uint64(info.Size())
-	a.Mtime = info.ModTime()
-	a.Mode = readonly(info.Mode())
-	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-		a.Inode = stat.Ino
-		//a.Atime = stat.Atim
-		//a.Ctime = stat.Ctim
-		a.Nlink = uint32(stat.Nlink)
-		a.Uid = stat.Uid
-		a.Gid = stat.Gid
+	return d.Attr(ctx, &resp.Attr)
+}
+
+// setattr applies whichever fields of req are valid to the backing
+// path. It is shared by Dir.Setattr and File.Setattr since both proxy
+// onto plain os calls keyed by path.
+func setattr(path string, req *fuse.SetattrRequest) error {
+	if req.Valid.Size() {
+		if err := os.Truncate(path, int64(req.Size)); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Mode() {
+		if err := os.Chmod(path, req.Mode); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Uid() || req.Valid.Gid() {
+		uid, gid := -1, -1
+		if req.Valid.Uid() {
+			uid = int(req.Uid)
+		}
+		if req.Valid.Gid() {
+			gid = int(req.Gid)
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Mtime() {
+		atime := req.Atime
+		if !req.Valid.Atime() {
+			if info, err := os.Lstat(path); err == nil {
+				if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+					atime = time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+				}
+			}
+		}
+		if err := os.Chtimes(path, atime, req.Mtime); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (f File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
-	actual, err := os.Open(string(f))
+func (f File) Attr(ctx context.Context, a *fuse.Attr) error {
+	info, err := os.Lstat(string(f))
 	if err != nil {
 		return err
 	}
-	defer actual.Close()
-	_, err = actual.Seek(req.Offset, os.SEEK_SET)
+	fillAttr(a, info)
+	if readOnlyMount {
+		a.Mode = readonly(a.Mode)
+	}
+	return nil
+}
+
+func (f File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	actual, err := os.OpenFile(string(f), int(req.Flags), 0)
 	if err != nil {
+		return nil, err
+	}
+	return &FileHandle{f: actual, appendOnly: req.Flags&fuse.OpenAppend != 0}, nil
+}
+
+func (f File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if err := setattr(string(f), req); err != nil {
 		return err
 	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+func (fh *FileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 	buf := make([]byte, req.Size)
-	_, err = io.ReadFull(actual, buf)
-	if err != nil {
+	n, err := fh.f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
 		return err
 	}
-	resp.Data = buf
+	resp.Data = buf[:n]
 	return nil
 }
 
-func (f File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
-	actual, err := os.Open(string(f))
-	if err != nil {
-		return err
-	}
-	defer actual.Close()
-	_, err = actual.Seek(req.Offset, os.SEEK_SET)
-	if err != nil {
-		return err
+func (fh *FileHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	// WriteAt rejects any offset-based write against a descriptor opened
+	// O_APPEND ("invalid use of WriteAt on file opened with O_APPEND"),
+	// so append-mode handles use plain Write instead and let the kernel
+	// position the write at the file's current end, as O_APPEND demands.
+	if fh.appendOnly {
+		n, err := fh.f.Write(req.Data)
+		if err != nil {
+			return err
+		}
+		resp.Size = n
+		return nil
 	}
-	_, err = actual.Write(req.Data)
+
+	n, err := fh.f.WriteAt(req.Data, req.Offset)
 	if err != nil {
 		return err
 	}
+	resp.Size = n
 	return nil
 }
 
-/*
-func (f File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
-	return nil
+func (fh *FileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return fh.f.Close()
+}
+
+func (fh *FileHandle) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	return fh.f.Sync()
 }
-*/
 
 func (l Symlink) Attr(ctx context.Context, a *fuse.Attr) error {
+	info, err := os.Lstat(string(l))
+	if err != nil {
+		return err
+	}
+	fillAttr(a, info)
 	return nil
 }
 
 func (l Symlink) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
-	return "", nil
+	return os.Readlink(string(l))
 }
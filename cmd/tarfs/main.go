@@ -0,0 +1,366 @@
+// Serve a .tar/.tar.gz archive read-only as a FUSE filesystem.
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+type Node interface {
+	fs.Node
+	dirent(name string) fuse.Dirent
+}
+
+type attr fuse.Attr
+
+type Dir struct {
+	attr
+	children map[string]Node
+}
+
+type File struct {
+	attr
+
+	// Set for entries read straight out of an uncompressed archive:
+	// Read seeks into archive at the recorded offset+size instead of
+	// holding the data in memory.
+	archive *os.File
+	offset  int64
+	size    int64
+
+	// Set instead of archive/offset/size for compressed archives
+	// (where the backing file offset doesn't correspond to the
+	// decompressed data) and for best-effort special files.
+	content []byte
+}
+
+type Symlink struct {
+	attr
+	target string
+}
+
+type FS struct {
+	root *Dir
+}
+
+func (fsys *FS) Root() (fs.Node, error) {
+	return fsys.root, nil
+}
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s MOUNTPOINT ARCHIVE\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		Usage()
+		os.Exit(2)
+	}
+	mountpoint := flag.Arg(0)
+	archivePath := flag.Arg(1)
+
+	root, archive, err := build(archivePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if archive != nil {
+		defer archive.Close()
+	}
+
+	c, err := fuse.Mount(
+		mountpoint,
+		fuse.FSName("tar"),
+		fuse.Subtype("tarfs"),
+		fuse.LocalVolume(),
+		fuse.ReadOnly(),
+		fuse.VolumeName("Tar Archive"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	err = fs.Serve(c, &FS{root: root})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	<-c.Ready
+	if err := c.MountError; err != nil {
+		log.Fatal(err)
+	}
+}
+
+var lastInode uint64
+
+func newNode(mode os.FileMode) attr {
+	lastInode++
+	now := time.Now()
+	return attr{
+		Inode: lastInode,
+		Mode:  mode,
+		Ctime: now,
+		Mtime: now,
+	}
+}
+
+// build streams archivePath once, constructing the in-memory directory
+// tree. For a plain (uncompressed) tar it keeps the opened *os.File
+// around so File.Read can seek directly into it; for a gzip-compressed
+// archive there is no stable offset to seek back to, so regular file
+// contents are buffered instead. The returned *os.File, if non-nil,
+// must be closed by the caller once the mount is done with it.
+func build(archivePath string) (*Dir, *os.File, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compressed := strings.HasSuffix(archivePath, ".gz") || strings.HasSuffix(archivePath, ".tgz")
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	root := newDir(os.ModeDir | 0755)
+	hardlinks := make(map[string]Node)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			// PAX global/per-file extended header entries (e.g. the
+			// "pax_global_header" git archive emits) are metadata about
+			// the entry that follows, not a file of their own; skip
+			// rather than materializing them as an empty regular file.
+			continue
+		}
+
+		// The data for this entry starts right after the header that
+		// tr.Next just consumed; for an uncompressed archive that
+		// offset in the backing file is stable and can be seeked back
+		// to later from File.Read.
+		var offset int64
+		if !compressed {
+			offset, err = f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+		}
+
+		name := path.Clean(hdr.Name)
+		dir, base := ensureParent(root, name)
+		if base == "" {
+			// the archive root entry itself ("./" or "")
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			ensureDir(root, name)
+		case tar.TypeReg, tar.TypeRegA:
+			var node *File
+			if compressed {
+				content, err := ioutil.ReadAll(tr)
+				if err != nil {
+					f.Close()
+					return nil, nil, err
+				}
+				node = newFileFromContent(os.FileMode(hdr.Mode), hdr.ModTime, content)
+			} else {
+				node = newFileFromArchive(os.FileMode(hdr.Mode), hdr.ModTime, f, offset, hdr.Size)
+			}
+			dir.children[base] = node
+			hardlinks[name] = node
+		case tar.TypeSymlink:
+			dir.children[base] = newSymlink(os.FileMode(hdr.Mode), hdr.Linkname)
+		case tar.TypeLink:
+			if target, ok := hardlinks[path.Clean(hdr.Linkname)]; ok {
+				dir.children[base] = target
+			}
+		default:
+			// char/block/fifo devices, best-effort: represent as an
+			// empty regular file rather than failing the whole mount.
+			dir.children[base] = newFileFromContent(os.FileMode(hdr.Mode), hdr.ModTime, nil)
+		}
+	}
+
+	return root, f, nil
+}
+
+// ensureParent walks from root to the directory containing name,
+// creating any missing intermediate directories (archives are not
+// required to list them explicitly), and returns that directory along
+// with name's base component.
+func ensureParent(root *Dir, name string) (*Dir, string) {
+	if name == "." || name == "" {
+		return root, ""
+	}
+	dir, base := path.Split(name)
+	return ensureDir(root, strings.TrimSuffix(dir, "/")), base
+}
+
+func ensureDir(root *Dir, name string) *Dir {
+	name = path.Clean(name)
+	if name == "." || name == "" {
+		return root
+	}
+	parent, base := ensureParent(root, name)
+	if base == "" {
+		return parent
+	}
+	child, ok := parent.children[base]
+	if !ok {
+		d := newDir(os.ModeDir | 0755)
+		parent.children[base] = d
+		return d
+	}
+	if d, ok := child.(*Dir); ok {
+		return d
+	}
+	// a file exists where the archive also wants a directory; prefer
+	// the directory so descendants have somewhere to live.
+	d := newDir(os.ModeDir | 0755)
+	parent.children[base] = d
+	return d
+}
+
+func newDir(mode os.FileMode) *Dir {
+	return &Dir{
+		attr:     newNode(mode),
+		children: make(map[string]Node),
+	}
+}
+
+func newFileFromArchive(mode os.FileMode, mtime time.Time, archive *os.File, offset, size int64) *File {
+	a := newNode(mode)
+	a.Mtime = mtime
+	a.Size = uint64(size)
+	return &File{attr: a, archive: archive, offset: offset, size: size}
+}
+
+func newFileFromContent(mode os.FileMode, mtime time.Time, content []byte) *File {
+	a := newNode(mode)
+	a.Mtime = mtime
+	a.Size = uint64(len(content))
+	return &File{attr: a, content: content}
+}
+
+func newSymlink(mode os.FileMode, target string) *Symlink {
+	a := newNode(os.ModeSymlink | mode)
+	return &Symlink{attr: a, target: target}
+}
+
+func (d *Dir) dirent(name string) fuse.Dirent {
+	return fuse.Dirent{Inode: d.Inode, Type: fuse.DT_Dir, Name: name}
+}
+
+func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
+	*a = fuse.Attr(d.attr)
+	return nil
+}
+
+func (d *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	if child, ok := d.children[name]; ok {
+		return child, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var dir []fuse.Dirent
+	for name, child := range d.children {
+		dir = append(dir, child.dirent(name))
+	}
+	return dir, nil
+}
+
+func (f *File) dirent(name string) fuse.Dirent {
+	return fuse.Dirent{Inode: f.Inode, Type: fuse.DT_File, Name: name}
+}
+
+func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
+	*a = fuse.Attr(f.attr)
+	return nil
+}
+
+func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	if f.archive == nil {
+		resp.Data = sliceAt(f.content, req.Offset, req.Size)
+		return nil
+	}
+
+	size := req.Size
+	if req.Offset >= f.size {
+		resp.Data = nil
+		return nil
+	}
+	if req.Offset+int64(size) > f.size {
+		size = int(f.size - req.Offset)
+	}
+	buf := make([]byte, size)
+	n, err := f.archive.ReadAt(buf, f.offset+req.Offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+func sliceAt(content []byte, offset int64, size int) []byte {
+	if offset >= int64(len(content)) {
+		return nil
+	}
+	end := offset + int64(size)
+	if end > int64(len(content)) {
+		end = int64(len(content))
+	}
+	return content[offset:end]
+}
+
+func (l *Symlink) dirent(name string) fuse.Dirent {
+	return fuse.Dirent{Inode: l.Inode, Type: fuse.DT_Link, Name: name}
+}
+
+func (l *Symlink) Attr(ctx context.Context, a *fuse.Attr) error {
+	*a = fuse.Attr(l.attr)
+	return nil
+}
+
+func (l *Symlink) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	return l.target, nil
+}